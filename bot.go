@@ -1,16 +1,21 @@
 package ircbot
 
 import (
-	"bufio"
 	"crypto/rand"
 	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"log"
+	mrand "math/rand"
 	"net"
 	"net/http"
-	"net/textproto"
 	"strings"
+	"sync"
 	"time"
+
+	"github.com/Zaibon/ircbot-lib/irc"
+	"github.com/Zaibon/ircbot-lib/web"
 )
 
 type IrcBot struct {
@@ -22,23 +27,68 @@ type IrcBot struct {
 	Server  string
 	Port    string
 	Channel []string
+	// channelMu guards Channel: join() reads it from the connect/reconnect
+	// goroutine, and String()/ListChannels from the caller's, while
+	// JoinChannel/PartChannel mutate it
+	// from the web control plane's HTTP handler goroutine.
+	channelMu sync.Mutex
 
 	// tcp communication
-	conn   net.Conn
-	reader *textproto.Reader
-	writer *textproto.Writer
-
-	// web interface
+	conn         net.Conn
+	reader       *irc.Decoder
+	writer       *irc.Encoder
+	lastActivity time.Time
+
+	// reconnection, used by HandleError's supervised reconnect loop
+	MaxRetries     int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Disconnected   chan struct{}
+
+	// PingTimeout is how long the bot tolerates silence from the server
+	// before sending a PING, and again before declaring the connection
+	// dead. Zero disables the watchdog.
+	PingTimeout time.Duration
+
+	// outbound flood protection
+	SendRate       time.Duration // interval at which the global bucket gains a token; up to SendBurst messages may go back to back
+	SendBurst      int           // global token bucket burst size
+	PerTargetRate  time.Duration // optional per-channel/per-nick rate limit; 0 disables it
+	PerTargetBurst int           // per-target token bucket burst size
+	MaxLineLength  int           // PRIVMSG payloads longer than this (bytes) are split across lines
+	QueueSize      int           // capacity of the outbound queue (Out channel buffer)
+	DropWhenFull   bool          // true: drop outbound messages once the queue is full instead of blocking
+	out            *sender
+
+	// web control plane, see HandleWeb/WebHandler
 	WebEnable bool
 	WebPort   string
+	// WebAuthTokens maps a bearer token to the route patterns it may call
+	// ("*" for every route). Empty disables auth on the control plane.
+	WebAuthTokens map[string][]string
+	webEvents     *eventBus
+
+	// IRCv3 capability negotiation, requested with CAP REQ during Connect
+	// (e.g. "message-tags", "server-time", "account-tag", "echo-message", "batch")
+	Capabilities []string
+
+	// SASL PLAIN credentials, used during capability negotiation when both are set
+	SASLUser     string
+	SASLPassword string
 
 	// crypto
 	Encrypted bool
 	config    tls.Config
 
 	// data flow
-	In    chan *IrcMsg
-	Out   chan *IrcMsg
+	In  chan *IrcMsg
+	Out chan *IrcMsg
+	// Error is buffered by one slot so a stray error from listen() or
+	// watchdog() doesn't block forever if it arrives while HandleError's
+	// single goroutine is already busy inside reconnect (sleeping out a
+	// backoff, or running connectOnce); connectOnce itself never sends to
+	// this channel, it returns handshake errors directly, so reconnect
+	// can't deadlock on it either.
 	Error chan error
 
 	// exit flag
@@ -47,18 +97,37 @@ type IrcBot struct {
 	//action handlers
 	Handlers map[string][]ActionFunc
 
+	// typed event dispatch, layered on top of Handlers
+	EventHandlers map[EventType][]EventHandler
+	middleware    []Middleware
+
 	//are we Joined in channel?
 	Joined bool
+
+	// dccWaiters holds the pending DCCSendPassive calls waiting on a peer's
+	// reply offer; see awaitDCCReply/notifyDCCWaiters in dcc.go.
+	dccWaiters   []*dccWaiter
+	dccWaitersMu sync.Mutex
 }
 
 func NewIrcBot() *IrcBot {
 	bot := IrcBot{
-		Handlers: make(map[string][]ActionFunc),
-		In:       make(chan *IrcMsg),
-		Out:      make(chan *IrcMsg),
-		Error:    make(chan error),
-		Exit:     make(chan bool),
-		Joined:   false,
+		Handlers:       make(map[string][]ActionFunc),
+		EventHandlers:  make(map[EventType][]EventHandler),
+		In:             make(chan *IrcMsg),
+		Error:          make(chan error, 1),
+		Exit:           make(chan bool),
+		Disconnected:   make(chan struct{}),
+		MaxRetries:     0, // 0 means retry forever
+		InitialBackoff: 1 * time.Second,
+		MaxBackoff:     5 * time.Minute,
+		PingTimeout:    3 * time.Minute,
+		SendRate:       1300 * time.Millisecond,
+		SendBurst:      1,
+		MaxLineLength:  400,
+		QueueSize:      100,
+		webEvents:      newEventBus(),
+		Joined:         false,
 	}
 
 	//defautl actions, needed to run proprely
@@ -73,48 +142,177 @@ func (b *IrcBot) url() string {
 }
 
 func (b *IrcBot) Connect() {
-	//launch a go routine that handle errors
-	// b.handleError()
+	// Out/out are built here rather than in NewIrcBot so that SendRate,
+	// SendBurst and QueueSize can still be set on the returned *IrcBot
+	// before Connect is called, the same way PingTimeout is only read
+	// once watchdog() starts.
+	b.Out = make(chan *IrcMsg, b.QueueSize)
+	b.out = newSender(b)
+
+	if err := b.connectOnce(); err != nil {
+		log.Fatalln("Error> unable to connect:", err)
+	}
+
+	//launch go routines that handle requests
+	b.handleActionIn()
+	b.handleActionOut()
+	b.HandleError()
+	b.watchdog()
+	if b.WebEnable {
+		b.HandleWeb()
+	}
 
+	//join all channels
+	b.join()
+}
+
+// connectOnce dials the server, runs the CAP/SASL handshake, sends
+// USER/NICK and starts the listener on the fresh connection. It is re-run
+// by HandleError's reconnect loop whenever the connection drops.
+func (b *IrcBot) connectOnce() error {
 	log.Println("Info> connection to", b.url())
 
 	var tcpCon net.Conn
 	var err error
 	if b.Encrypted {
 		cert, err := tls.LoadX509KeyPair("cert.pem", "key.pem")
-		b.errChk(err)
+		if err != nil {
+			return err
+		}
 
 		config := tls.Config{Certificates: []tls.Certificate{cert}}
 		config.Rand = rand.Reader
 		tcpCon, err = tls.Dial("tcp", b.url(), &config)
-		b.errChk(err)
+		if err != nil {
+			return err
+		}
 
 	} else {
 		tcpCon, err = net.Dial("tcp", b.url())
-		b.errChk(err)
+		if err != nil {
+			return err
+		}
 	}
 
-	b.conn = tcpCon
-	r := bufio.NewReader(b.conn)
-	w := bufio.NewWriter(b.conn)
-	b.reader = textproto.NewReader(r)
-	b.writer = textproto.NewWriter(w)
+	b.UseConn(tcpCon)
+
+	if err := b.negotiateCapabilities(); err != nil {
+		return err
+	}
 
 	//connect to server
-	b.writer.PrintfLine("USER %s 8 * :%s", b.Nick, b.Nick)
-	b.writer.PrintfLine("NICK %s", b.Nick)
+	b.writer.WriteLine(fmt.Sprintf("USER %s 8 * :%s", b.Nick, b.Nick))
+	b.writer.WriteLine(fmt.Sprintf("NICK %s", b.Nick))
 
-	//launch go routines that handle requests
 	b.listen()
-	b.handleActionIn()
-	b.handleActionOut()
-	b.HandleError()
-	if b.WebEnable {
-		b.HandleWeb()
+
+	return nil
+}
+
+// UseConn wires b to read and write IRC lines over conn through the
+// structured irc.Decoder/irc.Encoder, instead of dialing Server/Port
+// itself. connectOnce calls this once it has established its own
+// net.Dial/tls.Dial connection, but any net.Conn-like transport (a test
+// harness, a proxied connection) can be handed to it directly.
+func (b *IrcBot) UseConn(conn net.Conn) {
+	b.conn = conn
+	b.reader = irc.NewDecoder(conn)
+	b.writer = irc.NewEncoder(conn)
+	b.lastActivity = time.Now()
+}
+
+// negotiateCapabilities runs the IRCv3 CAP handshake before USER/NICK are
+// sent: CAP LS, CAP REQ for the wanted capabilities, optional SASL PLAIN
+// authentication, then CAP END. It is a no-op if neither Capabilities nor
+// SASL credentials are configured. Read errors are returned directly rather
+// than funneled through b.Error: this runs synchronously inside connectOnce,
+// before HandleError's goroutine exists on the very first Connect, and
+// again inside reconnect's single HandleError goroutine on every
+// reconnect — sending to b.Error from either place would have no reader
+// and block forever.
+func (b *IrcBot) negotiateCapabilities() error {
+	useSASL := b.SASLUser != "" && b.SASLPassword != ""
+	if len(b.Capabilities) == 0 && !useSASL {
+		return nil
 	}
 
-	//join all channels
-	b.join()
+	wanted := b.Capabilities
+	if useSASL {
+		wanted = append(wanted, "sasl")
+	}
+
+	b.writer.WriteLine("CAP LS 302")
+	b.writer.WriteLine(fmt.Sprintf("CAP REQ :%s", strings.Join(wanted, " ")))
+
+	var acked []string
+	for {
+		line, err := b.reader.ReadLine()
+		if err != nil {
+			return err
+		}
+		msg := Parseline(line)
+
+		if msg.Command == "CAP" && len(msg.Args) > 0 && (msg.Args[0] == "ACK" || msg.Args[0] == "NAK") {
+			if msg.Args[0] == "ACK" && len(msg.Args) > 1 {
+				acked = strings.Fields(msg.Args[1])
+			}
+			break
+		}
+	}
+
+	if useSASL && hasCapability(acked, "sasl") {
+		if err := b.authenticateSASL(); err != nil {
+			return err
+		}
+	}
+
+	b.writer.WriteLine("CAP END")
+	return nil
+}
+
+// hasCapability reports whether name is present in caps.
+func hasCapability(caps []string, name string) bool {
+	for _, c := range caps {
+		if c == name {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateSASL performs the SASL PLAIN exchange: AUTHENTICATE PLAIN,
+// wait for the "+" continuation, then send the base64 authzid\0authcid\0passwd
+// blob and wait for the 903 (success) or 904 (failure) numeric.
+func (b *IrcBot) authenticateSASL() error {
+	b.writer.WriteLine("AUTHENTICATE PLAIN")
+
+	for {
+		line, err := b.reader.ReadLine()
+		if err != nil {
+			return err
+		}
+		msg := Parseline(line)
+		if msg.Command == "AUTHENTICATE" {
+			break
+		}
+	}
+
+	payload := fmt.Sprintf("%s\x00%s\x00%s", b.SASLUser, b.SASLUser, b.SASLPassword)
+	b.writer.WriteLine(fmt.Sprintf("AUTHENTICATE %s", base64.StdEncoding.EncodeToString([]byte(payload))))
+
+	for {
+		line, err := b.reader.ReadLine()
+		if err != nil {
+			return err
+		}
+		msg := Parseline(line)
+		if msg.Command == "903" || msg.Command == "904" {
+			if msg.Command == "904" {
+				return fmt.Errorf("irc: SASL authentication failed")
+			}
+			return nil
+		}
+	}
 }
 
 func (b *IrcBot) join() {
@@ -128,10 +326,14 @@ func (b *IrcBot) join() {
 		break
 	}
 
-	for _, v := range b.Channel {
+	b.channelMu.Lock()
+	channels := append([]string{}, b.Channel...)
+	b.channelMu.Unlock()
+
+	for _, v := range channels {
 		s := fmt.Sprintf("JOIN %s", v)
 		fmt.Println("irc >> ", s)
-		b.writer.PrintfLine(s)
+		b.writer.WriteLine(s)
 	}
 	b.Joined = true
 }
@@ -145,7 +347,9 @@ func (b *IrcBot) listen() {
 			line, err := b.reader.ReadLine()
 			if err != nil {
 				b.Error <- err
+				return
 			}
+			b.lastActivity = time.Now()
 			//convert line into IrcMsg
 			msg := Parseline(line)
 			b.In <- msg
@@ -159,6 +363,20 @@ func (b *IrcBot) Say(s string) {
 	msg.Command = "PRIVMSG"
 	msg.Args = append(msg.Args, s)
 
+	b.enqueue(msg)
+}
+
+// enqueue pushes msg onto the outbound queue. It blocks while the queue is
+// full unless DropWhenFull is set, in which case the message is dropped.
+func (b *IrcBot) enqueue(msg *IrcMsg) {
+	if b.DropWhenFull {
+		select {
+		case b.Out <- msg:
+		default:
+			log.Println("Error> outbound queue full, dropping message:", msg.Raw)
+		}
+		return
+	}
 	b.Out <- msg
 }
 
@@ -172,12 +390,14 @@ func (b *IrcBot) handleActionIn() {
 			//receive new message
 			msg := <-b.In
 			fmt.Println("irc << ", msg.Raw)
-			//handle action
-			actions := b.Handlers[msg.Command]
-			if len(actions) > 0 {
-				for _, action := range actions {
-					action(b, msg)
-				}
+
+			//handle legacy raw-command actions, then typed events
+			b.dispatchRaw(msg)
+			b.dispatchEvent(classify(msg))
+
+			//stream the message to any /events subscriber
+			if payload, err := json.Marshal(msg); err == nil {
+				b.webEvents.publish(payload)
 			}
 		}
 	}()
@@ -193,49 +413,154 @@ func (b *IrcBot) handleActionOut() {
 				continue
 			}
 
-			s := fmt.Sprintf("%s %s %s", msg.Command, msg.Channel, strings.Join(msg.Args, " "))
-			fmt.Println("irc >> ", s)
-			b.writer.PrintfLine(s)
+			for _, line := range b.render(msg) {
+				b.out.wait(msg.Channel)
+				fmt.Println("irc >> ", line)
+				b.writer.WriteLine(line)
+			}
 		}
 	}()
 }
 
+// render turns msg into the raw protocol line(s) to send, splitting
+// PRIVMSG payloads longer than MaxLineLength bytes across several lines.
+// CTCP payloads (including DCC SEND/CHAT offers, framed in \x01 delimiters
+// by sendCTCP) are never split: breaking one across multiple PRIVMSGs would
+// corrupt its framing, so it's sent whole regardless of MaxLineLength.
+func (b *IrcBot) render(msg *IrcMsg) []string {
+	if msg.Command != "PRIVMSG" {
+		return []string{fmt.Sprintf("%s %s %s", msg.Command, msg.Channel, strings.Join(msg.Args, " "))}
+	}
+
+	text := strings.Join(msg.Args, " ")
+
+	if strings.HasPrefix(text, "\x01") {
+		return []string{fmt.Sprintf("%s %s %s", msg.Command, msg.Channel, text)}
+	}
+
+	var lines []string
+	for _, chunk := range splitMessage(text, b.MaxLineLength) {
+		lines = append(lines, fmt.Sprintf("%s %s %s", msg.Command, msg.Channel, chunk))
+	}
+	return lines
+}
+
 func (b *IrcBot) HandleError() {
 	go func() {
 		for {
 			err := <-b.Error
-			fmt.Printf("error >> %s", err)
-			if err != nil {
-				b.Disconnect()
-				log.Fatalln("Error ocurs :", err)
+			if err == nil {
+				continue
 			}
+			fmt.Printf("error >> %s", err)
+			b.reconnect()
 		}
 	}()
 }
 
-//HandleWeb handles requests receive on http server
-func (b *IrcBot) HandleWeb() {
+// reconnect tears down the current connection, notifies observers via the
+// DISCONNECTED event and the Disconnected channel, then retries connectOnce
+// with exponential backoff and jitter (capped at MaxBackoff) until it
+// succeeds or MaxRetries attempts have been made.
+func (b *IrcBot) reconnect() {
+	b.dispatchEvent(&Event{Type: EventDisconnected, Msg: NewIrcMsg()})
+
+	if b.conn != nil {
+		b.conn.Close()
+	}
+	b.Joined = false
+
+	select {
+	case b.Disconnected <- struct{}{}:
+	default:
+	}
+
+	backoff := b.InitialBackoff
+
+	for attempt := 1; ; attempt++ {
+		if b.MaxRetries > 0 && attempt > b.MaxRetries {
+			log.Fatalln("Error> giving up after", b.MaxRetries, "reconnect attempts")
+		}
+
+		wait := jitter(backoff)
+		log.Println("Info> reconnecting in", wait)
+		time.Sleep(wait)
+
+		if err := b.connectOnce(); err != nil {
+			log.Println("Error> reconnect attempt failed:", err)
+
+			backoff = capBackoff(backoff, b.MaxBackoff)
+			continue
+		}
+
+		b.join()
+		return
+	}
+}
+
+// jitter returns d adjusted by up to ±20%, so that many bots reconnecting
+// after the same outage don't all hammer the server at once.
+func jitter(d time.Duration) time.Duration {
+	delta := float64(d) * 0.2
+	offset := (mrand.Float64()*2 - 1) * delta
+	return d + time.Duration(offset)
+}
+
+// capBackoff doubles backoff, capping it at max so repeated reconnect
+// failures don't grow the wait unbounded.
+func capBackoff(backoff, max time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > max {
+		backoff = max
+	}
+	return backoff
+}
+
+// watchdog periodically checks for server silence. After PingTimeout of
+// inactivity it sends a PING; after another PingTimeout without a reply (or
+// any other server traffic), it reports the connection as dead so
+// HandleError can reconnect. Disabled when PingTimeout is zero.
+func (b *IrcBot) watchdog() {
+	if b.PingTimeout <= 0 {
+		return
+	}
+
 	go func() {
-		http.HandleFunc("/qg", Gui)
-		http.HandleFunc("/send", func(w http.ResponseWriter, r *http.Request) {
-			Send(b, w, r)
-		})
-		http.HandleFunc("/ircbot", func(w http.ResponseWriter, r *http.Request) {
-			Handler(b, w, r)
-		})
-		http.ListenAndServe(":"+b.WebPort, nil)
+		ticker := time.NewTicker(b.PingTimeout / 2)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			idle := time.Since(b.lastActivity)
+
+			switch {
+			case idle >= 2*b.PingTimeout:
+				b.Error <- fmt.Errorf("ping timeout: no server traffic for %s", idle)
+			case idle >= b.PingTimeout:
+				b.writer.WriteLine("PING :watchdog")
+			}
+		}
 	}()
 }
 
-func (b *IrcBot) errChk(err error) {
-	if err != nil {
-		log.Println("Error> ", err)
-		b.Error <- err
-	}
+// WebHandler returns the REST + WebSocket control plane as a plain
+// http.Handler, so it can be mounted under a caller's own router or TLS
+// server instead of owning the whole process.
+func (b *IrcBot) WebHandler() http.Handler {
+	return web.NewHandler(b, web.Config{Tokens: b.WebAuthTokens})
+}
+
+// HandleWeb serves the control plane returned by WebHandler on WebPort.
+func (b *IrcBot) HandleWeb() {
+	go func() {
+		log.Println("Info> web control plane listening on :" + b.WebPort)
+		if err := http.ListenAndServe(":"+b.WebPort, b.WebHandler()); err != nil {
+			log.Println("Error> web control plane:", err)
+		}
+	}()
 }
 
 func (b *IrcBot) Disconnect() {
-	b.writer.PrintfLine("QUIT")
+	b.writer.WriteLine("QUIT")
 	b.conn.Close()
 }
 
@@ -244,9 +569,13 @@ func (b *IrcBot) String() string {
 	s += fmt.Sprintf("port: %s\n", b.Port)
 	s += fmt.Sprintf("ssl: %t\n", b.Encrypted)
 
-	if len(b.Channel) > 0 {
+	b.channelMu.Lock()
+	channels := append([]string{}, b.Channel...)
+	b.channelMu.Unlock()
+
+	if len(channels) > 0 {
 		s += "channels: "
-		for _, v := range b.Channel {
+		for _, v := range channels {
 			s += fmt.Sprintf("%s ", v)
 		}
 	}
@@ -0,0 +1,45 @@
+package ircbot
+
+import (
+	"testing"
+	"time"
+)
+
+func TestJitterWithinBounds(t *testing.T) {
+	d := 10 * time.Second
+	delta := float64(d) * 0.2
+
+	for i := 0; i < 1000; i++ {
+		got := jitter(d)
+		if float64(got) < float64(d)-delta || float64(got) > float64(d)+delta {
+			t.Fatalf("jitter(%s) = %s, want within ±20%% of %s", d, got, d)
+		}
+	}
+}
+
+func TestJitterZero(t *testing.T) {
+	if got := jitter(0); got != 0 {
+		t.Fatalf("jitter(0) = %s, want 0", got)
+	}
+}
+
+func TestCapBackoff(t *testing.T) {
+	tests := []struct {
+		name    string
+		backoff time.Duration
+		max     time.Duration
+		want    time.Duration
+	}{
+		{"doubles below cap", 1 * time.Second, 1 * time.Minute, 2 * time.Second},
+		{"caps when doubling would exceed max", 40 * time.Second, 1 * time.Minute, 1 * time.Minute},
+		{"stays at cap once reached", 1 * time.Minute, 1 * time.Minute, 1 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := capBackoff(tt.backoff, tt.max); got != tt.want {
+				t.Errorf("capBackoff(%s, %s) = %s, want %s", tt.backoff, tt.max, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,276 @@
+package ircbot
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DCCOffer describes a DCC SEND or CHAT request carried over CTCP, whether
+// offered by us or received from a peer. Incoming offers are dispatched as
+// a DCC_OFFER event for user code to accept or reject.
+type DCCOffer struct {
+	Nick     string
+	Type     string // "SEND" or "CHAT"
+	Filename string // set for SEND
+	IP       net.IP
+	Port     int
+	Size     int64  // set for SEND
+	Token    string // set for passive (reverse) DCC SEND
+}
+
+// ParseDCCOffer extracts the DCC details out of a DCC_OFFER event's IrcMsg.
+func ParseDCCOffer(msg *IrcMsg) (*DCCOffer, error) {
+	fields := strings.Fields(ctcpPayload(msg))
+	if len(fields) < 2 || fields[0] != "DCC" {
+		return nil, fmt.Errorf("dcc: not a DCC offer: %q", msg.Raw)
+	}
+
+	offer := &DCCOffer{Nick: msg.Nick, Type: strings.ToUpper(fields[1])}
+
+	switch offer.Type {
+	case "CHAT":
+		if len(fields) < 5 {
+			return nil, fmt.Errorf("dcc: malformed CHAT offer: %q", msg.Raw)
+		}
+		offer.IP = decodeDCCIP(fields[3])
+		offer.Port, _ = strconv.Atoi(fields[4])
+
+	case "SEND":
+		if len(fields) < 6 {
+			return nil, fmt.Errorf("dcc: malformed SEND offer: %q", msg.Raw)
+		}
+		offer.Filename = fields[2]
+		offer.IP = decodeDCCIP(fields[3])
+		offer.Port, _ = strconv.Atoi(fields[4])
+		offer.Size, _ = strconv.ParseInt(fields[5], 10, 64)
+		if len(fields) >= 7 {
+			offer.Token = fields[6]
+		}
+
+	default:
+		return nil, fmt.Errorf("dcc: unsupported DCC type %q", offer.Type)
+	}
+
+	return offer, nil
+}
+
+// DCCSend offers path to nick over an active DCC SEND: it opens a listening
+// socket, announces it via CTCP, accepts the peer and streams the file.
+func (b *IrcBot) DCCSend(nick, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	name := filepath.Base(path)
+
+	b.sendCTCP(nick, fmt.Sprintf("DCC SEND %s %d %d %d", name, encodeDCCIP(b.localIP()), port, info.Size()))
+
+	conn, err := ln.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return sendDCCFile(conn, f)
+}
+
+// DCCSendPassive offers path to nick using passive (reverse) DCC: since we
+// may be behind NAT, we don't listen ourselves but instead wait for the
+// peer's own DCC SEND reply, carrying our token alongside the address and
+// port we should dial.
+func (b *IrcBot) DCCSendPassive(nick, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Base(path)
+	token := strconv.FormatInt(time.Now().UnixNano(), 36)
+
+	b.sendCTCP(nick, fmt.Sprintf("DCC SEND %s %d 0 %d %s", name, encodeDCCIP(b.localIP()), info.Size(), token))
+
+	reply, err := b.awaitDCCReply(nick, token)
+	if err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("tcp", fmt.Sprintf("%s:%d", reply.IP, reply.Port))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return sendDCCFile(conn, f)
+}
+
+// DCCChat offers nick a DCC CHAT session: it opens a listening socket,
+// announces it via CTCP, and returns the accepted peer connection as an
+// io.ReadWriteCloser once they connect.
+func (b *IrcBot) DCCChat(nick string) (io.ReadWriteCloser, error) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer ln.Close()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+
+	b.sendCTCP(nick, fmt.Sprintf("DCC CHAT chat %d %d", encodeDCCIP(b.localIP()), port))
+
+	return ln.Accept()
+}
+
+// sendCTCP enqueues a PRIVMSG to target wrapping payload in the \x01
+// delimiters CTCP requires.
+func (b *IrcBot) sendCTCP(target, payload string) {
+	msg := NewIrcMsg()
+	msg.Command = "PRIVMSG"
+	msg.Channel = target
+	msg.Args = []string{"\x01" + payload + "\x01"}
+	b.enqueue(msg)
+}
+
+// dccWaiter is a single pending DCCSendPassive call, waiting for its peer's
+// reply offer. Unlike registering through AddEventHandler, a waiter is
+// removed as soon as it's matched or abandoned, so repeated passive sends
+// don't accumulate stale handlers on a long-running bot.
+type dccWaiter struct {
+	nick  string
+	token string
+	ch    chan *DCCOffer
+}
+
+// awaitDCCReply blocks until a DCC_OFFER from nick carrying token arrives,
+// or 2 minutes pass.
+func (b *IrcBot) awaitDCCReply(nick, token string) (*DCCOffer, error) {
+	w := &dccWaiter{nick: nick, token: token, ch: make(chan *DCCOffer, 1)}
+
+	b.dccWaitersMu.Lock()
+	b.dccWaiters = append(b.dccWaiters, w)
+	b.dccWaitersMu.Unlock()
+
+	defer b.removeDCCWaiter(w)
+
+	select {
+	case offer := <-w.ch:
+		return offer, nil
+	case <-time.After(2 * time.Minute):
+		return nil, fmt.Errorf("dcc: timed out waiting for passive reply from %s", nick)
+	}
+}
+
+func (b *IrcBot) removeDCCWaiter(target *dccWaiter) {
+	b.dccWaitersMu.Lock()
+	defer b.dccWaitersMu.Unlock()
+
+	for i, w := range b.dccWaiters {
+		if w == target {
+			b.dccWaiters = append(b.dccWaiters[:i], b.dccWaiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// notifyDCCWaiters wakes any DCCSendPassive call waiting for msg's offer.
+// Called from dispatchEvent for every EventDCCOffer, alongside the normal
+// user-registered handlers.
+func (b *IrcBot) notifyDCCWaiters(msg *IrcMsg) {
+	offer, err := ParseDCCOffer(msg)
+	if err != nil {
+		return
+	}
+
+	b.dccWaitersMu.Lock()
+	defer b.dccWaitersMu.Unlock()
+
+	for _, w := range b.dccWaiters {
+		if w.nick == offer.Nick && w.token == offer.Token {
+			select {
+			case w.ch <- offer:
+			default:
+			}
+		}
+	}
+}
+
+// localIP returns the address the current connection is seen from, used to
+// advertise where a DCC peer should connect back to.
+func (b *IrcBot) localIP() net.IP {
+	if tcpAddr, ok := b.conn.LocalAddr().(*net.TCPAddr); ok {
+		return tcpAddr.IP
+	}
+	return net.IPv4zero
+}
+
+// sendDCCFile streams f to conn, honoring the 4-byte big-endian ACK the
+// receiver sends back for every chunk it has written to disk.
+func sendDCCFile(conn net.Conn, f *os.File) error {
+	buf := make([]byte, 4096)
+	ack := make([]byte, 4)
+
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			if _, werr := conn.Write(buf[:n]); werr != nil {
+				return werr
+			}
+			if _, rerr := io.ReadFull(conn, ack); rerr != nil {
+				return rerr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// encodeDCCIP packs an IPv4 address into the big-endian 32-bit integer form
+// the DCC spec uses on the wire.
+func encodeDCCIP(ip net.IP) uint32 {
+	ip4 := ip.To4()
+	if ip4 == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(ip4)
+}
+
+// decodeDCCIP is the inverse of encodeDCCIP.
+func decodeDCCIP(s string) net.IP {
+	n, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil
+	}
+	ip := make(net.IP, 4)
+	binary.BigEndian.PutUint32(ip, uint32(n))
+	return ip
+}
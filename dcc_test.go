@@ -0,0 +1,102 @@
+package ircbot
+
+import (
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDCCIP(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   net.IP
+		want uint32
+	}{
+		{"loopback", net.ParseIP("127.0.0.1"), 0x7F000001},
+		{"example address", net.ParseIP("198.51.100.2"), 0xC6336402},
+		{"unspecified", net.ParseIP("0.0.0.0"), 0},
+		{"nil IP", nil, 0},
+		{"non-IPv4 address", net.ParseIP("::1"), 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := encodeDCCIP(tt.ip); got != tt.want {
+				t.Errorf("encodeDCCIP(%v) = %#x, want %#x", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeDCCIP(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want net.IP
+	}{
+		{"loopback", "2130706433", net.IPv4(127, 0, 0, 1).To4()},
+		{"example address", "3325256706", net.IPv4(198, 51, 100, 2).To4()},
+		{"not a number", "not-a-number", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := decodeDCCIP(tt.s)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("decodeDCCIP(%q) = %v, want nil", tt.s, got)
+				}
+				return
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("decodeDCCIP(%q) = %v, want %v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEncodeDecodeDCCIPRoundTrip(t *testing.T) {
+	ip := net.ParseIP("203.0.113.42")
+
+	encoded := encodeDCCIP(ip)
+	decoded := decodeDCCIP(strconv.FormatUint(uint64(encoded), 10))
+
+	if !decoded.Equal(ip) {
+		t.Fatalf("round trip: got %v, want %v", decoded, ip)
+	}
+}
+
+func TestRenderDoesNotSplitCTCP(t *testing.T) {
+	b := &IrcBot{MaxLineLength: 20}
+
+	payload := "\x01DCC SEND " + strings.Repeat("x", 40) + ".txt 3232235777 0 1024 abc123\x01"
+	msg := NewIrcMsg()
+	msg.Command = "PRIVMSG"
+	msg.Channel = "nick"
+	msg.Args = []string{payload}
+
+	lines := b.render(msg)
+
+	if len(lines) != 1 {
+		t.Fatalf("render() split a CTCP payload into %d lines, want 1: %q", len(lines), lines)
+	}
+	if !strings.HasSuffix(lines[0], "\x01") || strings.Count(lines[0], "\x01") != 2 {
+		t.Fatalf("render() corrupted CTCP framing: %q", lines[0])
+	}
+}
+
+func TestRenderSplitsOrdinaryPRIVMSG(t *testing.T) {
+	b := &IrcBot{MaxLineLength: 10}
+
+	msg := NewIrcMsg()
+	msg.Command = "PRIVMSG"
+	msg.Channel = "#chan"
+	msg.Args = []string{strings.Repeat("a", 25)}
+
+	lines := b.render(msg)
+
+	if len(lines) != 3 {
+		t.Fatalf("render() produced %d lines, want 3: %q", len(lines), lines)
+	}
+}
@@ -0,0 +1,153 @@
+package ircbot
+
+import (
+	"log"
+	"strings"
+)
+
+// EventType identifies a high-level bot event derived from the raw IRC
+// stream, as opposed to the raw verb carried by IrcMsg.Command.
+type EventType string
+
+const (
+	EventConnected    EventType = "CONNECTED"
+	EventJoined       EventType = "JOINED"
+	EventPart         EventType = "PART"
+	EventChannelMsg   EventType = "PRIVMSG_CHANNEL"
+	EventPrivateMsg   EventType = "PRIVMSG_BOT"
+	EventCTCP         EventType = "CTCP"
+	EventNotice       EventType = "NOTICE"
+	EventNickChange   EventType = "NICK"
+	EventKick         EventType = "KICK"
+	EventDisconnected EventType = "DISCONNECTED"
+	EventDCCOffer     EventType = "DCC_OFFER"
+)
+
+// Event is the typed, high-level counterpart of a raw IrcMsg.
+type Event struct {
+	Type EventType
+	Msg  *IrcMsg
+}
+
+// EventHandler handles a typed Event. Returning an error short-circuits the
+// remaining handlers registered for the same EventType.
+type EventHandler func(bot *IrcBot, evt *Event) error
+
+// Middleware wraps an EventHandler, e.g. for logging, rate-limiting or ACLs.
+// Middleware registered with IrcBot.Use wraps every handler dispatched,
+// whether registered through AddEventHandler or the legacy AddAction.
+type Middleware func(next EventHandler) EventHandler
+
+// AddEventHandler registers h to run whenever an event of type t is
+// dispatched.
+func (b *IrcBot) AddEventHandler(t EventType, h EventHandler) {
+	b.EventHandlers[t] = append(b.EventHandlers[t], h)
+}
+
+// Use registers a global middleware, applied to every handler in the order
+// it was added.
+func (b *IrcBot) Use(mw Middleware) {
+	b.middleware = append(b.middleware, mw)
+}
+
+// applyMiddleware wraps h with every registered middleware, outermost first.
+func (b *IrcBot) applyMiddleware(h EventHandler) EventHandler {
+	wrapped := h
+	for i := len(b.middleware) - 1; i >= 0; i-- {
+		wrapped = b.middleware[i](wrapped)
+	}
+	return wrapped
+}
+
+// dispatchEvent runs every handler registered for evt.Type through the
+// middleware chain, stopping as soon as one returns an error.
+func (b *IrcBot) dispatchEvent(evt *Event) {
+	if evt == nil {
+		return
+	}
+
+	if evt.Type == EventDCCOffer {
+		b.notifyDCCWaiters(evt.Msg)
+	}
+
+	for _, h := range b.EventHandlers[evt.Type] {
+		if err := b.applyMiddleware(h)(b, evt); err != nil {
+			log.Println("Error> event handler:", err)
+			break
+		}
+	}
+}
+
+// dispatchRaw runs the legacy, raw-command handlers registered through
+// AddAction. It is a thin adapter that mirrors dispatchEvent: each
+// ActionFunc is wrapped as an EventHandler so it goes through the same
+// middleware chain, and stops on the same errors, as typed events.
+func (b *IrcBot) dispatchRaw(msg *IrcMsg) {
+	for _, action := range b.Handlers[msg.Command] {
+		handler := func(bot *IrcBot, evt *Event) error {
+			action(bot, evt.Msg)
+			return nil
+		}
+		if err := b.applyMiddleware(handler)(b, &Event{Type: EventType(msg.Command), Msg: msg}); err != nil {
+			log.Println("Error> action handler:", err)
+			break
+		}
+	}
+}
+
+// classify derives the high-level Event carried by a raw IrcMsg, or nil if
+// the command doesn't map to one of the known event types.
+func classify(msg *IrcMsg) *Event {
+	switch msg.Command {
+	case "001":
+		return &Event{Type: EventConnected, Msg: msg}
+	case "JOIN":
+		return &Event{Type: EventJoined, Msg: msg}
+	case "PART":
+		return &Event{Type: EventPart, Msg: msg}
+	case "NICK":
+		return &Event{Type: EventNickChange, Msg: msg}
+	case "KICK":
+		return &Event{Type: EventKick, Msg: msg}
+	case "NOTICE":
+		return &Event{Type: EventNotice, Msg: msg}
+	case "PRIVMSG":
+		if isCTCP(msg) {
+			if strings.HasPrefix(ctcpPayload(msg), "DCC ") {
+				return &Event{Type: EventDCCOffer, Msg: msg}
+			}
+			return &Event{Type: EventCTCP, Msg: msg}
+		}
+		if strings.HasPrefix(msg.Channel, "#") {
+			return &Event{Type: EventChannelMsg, Msg: msg}
+		}
+		return &Event{Type: EventPrivateMsg, Msg: msg}
+	default:
+		return nil
+	}
+}
+
+// isCTCP reports whether a PRIVMSG carries a CTCP-quoted payload, i.e. an
+// argument wrapped in \x01.
+func isCTCP(msg *IrcMsg) bool {
+	for _, arg := range msg.Args {
+		if strings.HasPrefix(arg, "\x01") {
+			return true
+		}
+	}
+	return strings.HasPrefix(msg.Channel, "\x01")
+}
+
+// ctcpPayload returns the unwrapped CTCP payload of msg (without the \x01
+// delimiters), or "" if msg doesn't carry one.
+func ctcpPayload(msg *IrcMsg) string {
+	for _, arg := range msg.Args {
+		if strings.HasPrefix(arg, "\x01") {
+			return strings.Trim(arg, "\x01")
+		}
+	}
+	if strings.HasPrefix(msg.Channel, "\x01") {
+		return strings.Trim(msg.Channel, "\x01")
+	}
+	return ""
+}
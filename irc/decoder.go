@@ -0,0 +1,43 @@
+package irc
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Decoder reads IRC messages from an underlying io.Reader, one per line.
+type Decoder struct {
+	r *bufio.Reader
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: bufio.NewReader(r)}
+}
+
+// ReadLine reads the next line, stripped of its trailing "\r\n", without
+// parsing it. It's used by callers that need the raw line itself, e.g. to
+// adapt it onto a different message shape than Message.
+func (d *Decoder) ReadLine() (string, error) {
+	line, err := d.r.ReadString('\n')
+	if err != nil && line == "" {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// Decode reads and parses the next message. It returns the underlying read
+// error (e.g. io.EOF) once there is nothing left to read.
+func (d *Decoder) Decode() (*Message, error) {
+	line, err := d.ReadLine()
+	if err != nil {
+		return nil, err
+	}
+	if line == "" {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return Parse(line)
+}
@@ -0,0 +1,26 @@
+package irc
+
+import "io"
+
+// Encoder writes IRC messages to an underlying io.Writer, one per line.
+type Encoder struct {
+	w io.Writer
+}
+
+// NewEncoder returns an Encoder writing to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Encode writes m to the underlying writer, terminated with "\r\n".
+func (e *Encoder) Encode(m *Message) error {
+	return e.WriteLine(m.String())
+}
+
+// WriteLine writes line as-is, appending "\r\n". It's used by callers that
+// already have a fully-formatted protocol line instead of a Message, e.g.
+// pre-split PRIVMSG payloads.
+func (e *Encoder) WriteLine(line string) error {
+	_, err := io.WriteString(e.w, line+"\r\n")
+	return err
+}
@@ -0,0 +1,226 @@
+// Package irc implements a structured IRC message encoder/decoder, decoupled
+// from any particular transport so it can sit on top of a net.Conn, a TLS
+// connection, or anything else that looks like an io.Reader/io.Writer.
+package irc
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrMissingCommand is returned by Parse when a line has no command.
+var ErrMissingCommand = errors.New("irc: missing command")
+
+// Prefix identifies the origin of a Message: either a server name (Host
+// only) or a user (Nick, optionally User and Host).
+type Prefix struct {
+	Nick string
+	User string
+	Host string
+}
+
+func (p Prefix) String() string {
+	s := p.Nick
+	if p.User != "" {
+		s += "!" + p.User
+	}
+	if p.Host != "" {
+		s += "@" + p.Host
+	}
+	return s
+}
+
+// Message is a fully parsed IRC protocol line.
+type Message struct {
+	Tags    map[string]string
+	Prefix  Prefix
+	Command string
+	Params  []string
+
+	// Trailing is the last parameter when it was introduced with " :",
+	// kept separate from Params since it may contain spaces.
+	Trailing string
+	// HasTrailing reports whether a trailing parameter was actually
+	// present, since an explicit empty one ("PRIVMSG #chan :") is
+	// otherwise indistinguishable from there being no trailing param at
+	// all.
+	HasTrailing bool
+}
+
+// String renders m back into a wire-format IRC line, without the trailing
+// "\r\n".
+func (m *Message) String() string {
+	var b strings.Builder
+
+	if len(m.Tags) > 0 {
+		b.WriteByte('@')
+		first := true
+		for k, v := range m.Tags {
+			if !first {
+				b.WriteByte(';')
+			}
+			first = false
+			b.WriteString(k)
+			if v != "" {
+				b.WriteByte('=')
+				b.WriteString(escapeTag(v))
+			}
+		}
+		b.WriteByte(' ')
+	}
+
+	if m.Prefix != (Prefix{}) {
+		b.WriteByte(':')
+		b.WriteString(m.Prefix.String())
+		b.WriteByte(' ')
+	}
+
+	b.WriteString(m.Command)
+
+	for _, p := range m.Params {
+		b.WriteByte(' ')
+		b.WriteString(p)
+	}
+
+	if m.HasTrailing {
+		b.WriteString(" :")
+		b.WriteString(m.Trailing)
+	}
+
+	return b.String()
+}
+
+// Parse decodes a single raw IRC line (without the trailing "\r\n") into a
+// Message. Unlike the old hand-rolled IrcMsg.Parseline, which now delegates
+// to Parse, it correctly splits params on
+// the first " :" to find the trailing argument, so a message body such as
+// "hello : world" is not mistaken for a second parameter list.
+func Parse(line string) (*Message, error) {
+	msg := &Message{Tags: make(map[string]string)}
+
+	if strings.HasPrefix(line, "@") {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			return nil, errors.New("irc: malformed tags")
+		}
+		parseTags(line[1:end], msg.Tags)
+		line = line[end+1:]
+	}
+
+	if strings.HasPrefix(line, ":") {
+		end := strings.IndexByte(line, ' ')
+		if end < 0 {
+			return nil, errors.New("irc: malformed prefix")
+		}
+		msg.Prefix = parsePrefix(line[1:end])
+		line = line[end+1:]
+	}
+
+	if i := strings.Index(line, " :"); i >= 0 {
+		msg.Trailing = line[i+2:]
+		msg.HasTrailing = true
+		line = line[:i]
+	} else if strings.HasPrefix(line, ":") {
+		msg.Trailing = line[1:]
+		msg.HasTrailing = true
+		line = ""
+	}
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return nil, ErrMissingCommand
+	}
+
+	msg.Command = strings.ToUpper(fields[0])
+	msg.Params = fields[1:]
+
+	return msg, nil
+}
+
+func parsePrefix(s string) Prefix {
+	p := Prefix{}
+
+	if i := strings.IndexByte(s, '@'); i >= 0 {
+		p.Host = s[i+1:]
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '!'); i >= 0 {
+		p.User = s[i+1:]
+		s = s[:i]
+	}
+	p.Nick = s
+
+	return p
+}
+
+// parseTags splits a raw "key=value;key2=value2" tag segment (without the
+// leading '@') and fills dst with the unescaped values.
+func parseTags(raw string, dst map[string]string) {
+	for _, tag := range strings.Split(raw, ";") {
+		if tag == "" {
+			continue
+		}
+
+		kv := strings.SplitN(tag, "=", 2)
+		if len(kv) == 2 {
+			dst[kv[0]] = unescapeTag(kv[1])
+		} else {
+			dst[kv[0]] = ""
+		}
+	}
+}
+
+// unescapeTag decodes the backslash escapes defined by the IRCv3
+// message-tags spec: \: -> ;, \s -> space, \\ -> \, \r and \n.
+func unescapeTag(s string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+
+		i++
+		switch s[i] {
+		case ':':
+			b.WriteByte(';')
+		case 's':
+			b.WriteByte(' ')
+		case 'r':
+			b.WriteByte('\r')
+		case 'n':
+			b.WriteByte('\n')
+		case '\\':
+			b.WriteByte('\\')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+
+	return b.String()
+}
+
+// escapeTag is the inverse of unescapeTag, used when encoding a Message.
+func escapeTag(s string) string {
+	var b strings.Builder
+
+	for _, r := range s {
+		switch r {
+		case ';':
+			b.WriteString(`\:`)
+		case ' ':
+			b.WriteString(`\s`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\n':
+			b.WriteString(`\n`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return b.String()
+}
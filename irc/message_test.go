@@ -0,0 +1,135 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want *Message
+	}{
+		{
+			name: "simple command",
+			line: "PING :tolsun.oulu.fi",
+			want: &Message{Tags: map[string]string{}, Command: "PING", Params: []string{}, Trailing: "tolsun.oulu.fi", HasTrailing: true},
+		},
+		{
+			name: "prefixed command with params and trailing",
+			line: ":Angel!wings@irc.org PRIVMSG Wiz :Are you receiving this message ?",
+			want: &Message{
+				Tags:        map[string]string{},
+				Prefix:      Prefix{Nick: "Angel", User: "wings", Host: "irc.org"},
+				Command:     "PRIVMSG",
+				Params:      []string{"Wiz"},
+				Trailing:    "Are you receiving this message ?",
+				HasTrailing: true,
+			},
+		},
+		{
+			name: "trailing param containing a colon is not mistaken for a second param list",
+			line: "PRIVMSG #chan :hello : world",
+			want: &Message{Tags: map[string]string{}, Command: "PRIVMSG", Params: []string{"#chan"}, Trailing: "hello : world", HasTrailing: true},
+		},
+		{
+			name: "no trailing param at all",
+			line: "MODE #chan +o nick",
+			want: &Message{Tags: map[string]string{}, Command: "MODE", Params: []string{"#chan", "+o", "nick"}},
+		},
+		{
+			name: "explicit empty trailing param is preserved",
+			line: "PRIVMSG #chan :",
+			want: &Message{Tags: map[string]string{}, Command: "PRIVMSG", Params: []string{"#chan"}, Trailing: "", HasTrailing: true},
+		},
+		{
+			name: "message tags",
+			line: "@id=123;time=2020-01-01T00:00:00Z :nick!user@host PRIVMSG #chan :hi",
+			want: &Message{
+				Tags:        map[string]string{"id": "123", "time": "2020-01-01T00:00:00Z"},
+				Prefix:      Prefix{Nick: "nick", User: "user", Host: "host"},
+				Command:     "PRIVMSG",
+				Params:      []string{"#chan"},
+				Trailing:    "hi",
+				HasTrailing: true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.line)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"@malformed-tags",
+		":malformed-prefix",
+	}
+
+	for _, line := range tests {
+		if _, err := Parse(line); err == nil {
+			t.Errorf("Parse(%q) returned no error, want one", line)
+		}
+	}
+}
+
+func TestMessageStringRoundTrip(t *testing.T) {
+	lines := []string{
+		"PING :tolsun.oulu.fi",
+		"MODE #chan +o nick",
+		"PRIVMSG #chan :",
+	}
+
+	for _, line := range lines {
+		msg, err := Parse(line)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", line, err)
+		}
+		if got := msg.String(); got != line {
+			t.Errorf("Parse(%q).String() = %q, want %q", line, got, line)
+		}
+	}
+}
+
+func TestUnescapeTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`hello\sworld`, "hello world"},
+		{`a\:b`, "a;b"},
+		{`a\\b`, `a\b`},
+		{`a\rb`, "a\rb"},
+		{`a\nb`, "a\nb"},
+		{"plain", "plain"},
+		{`trailing\`, `trailing\`},
+	}
+
+	for _, tt := range tests {
+		if got := unescapeTag(tt.in); got != tt.want {
+			t.Errorf("unescapeTag(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeUnescapeTagRoundTrip(t *testing.T) {
+	values := []string{"hello world", "a;b", `a\b`, "a\rb\nc", "plain"}
+
+	for _, v := range values {
+		escaped := escapeTag(v)
+		if got := unescapeTag(escaped); got != v {
+			t.Errorf("unescapeTag(escapeTag(%q)) = %q, want %q", v, got, v)
+		}
+	}
+}
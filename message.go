@@ -1,7 +1,7 @@
 package ircbot
 
 import (
-	"strings"
+	"github.com/Zaibon/ircbot-lib/irc"
 )
 
 type IrcMsg struct {
@@ -14,39 +14,58 @@ type IrcMsg struct {
 	Args    []string
 
 	Channel string
+
+	// Tags holds the IRCv3 message-tags prefixed to the line
+	// (@key=value;key2=value2 :prefix CMD ...), already unescaped.
+	Tags map[string]string
 }
 
 func NewIrcMsg() *IrcMsg {
-	return &IrcMsg{}
+	return &IrcMsg{Tags: make(map[string]string)}
 }
 
+// Parseline decodes line through the structured irc package and adapts the
+// result onto the legacy IrcMsg shape, so existing ActionFunc handlers keep
+// working unchanged. Unlike the old hand-rolled parser, the trailing
+// argument is no longer split on spaces: "PRIVMSG #chan :hello there" now
+// yields Args == []string{"hello there"} instead of {":hello", "there"}.
 func (m *IrcMsg) Parseline(line string) {
 	m.Raw = line
 
-	fields := strings.Fields(line)
+	msg, err := irc.Parse(line)
+	if err != nil {
+		m.Tags = make(map[string]string)
+		return
+	}
 
-	if strings.HasPrefix(line, ":") {
-		//action of a user
+	m.fromIRC(msg)
+}
 
-		m.Prefix = fields[0]
+// fromIRC fills the legacy fields of m from a structured irc.Message.
+func (m *IrcMsg) fromIRC(msg *irc.Message) {
+	m.Tags = msg.Tags
+	if m.Tags == nil {
+		m.Tags = make(map[string]string)
+	}
 
-		i := strings.Index(m.Prefix, "!")
-		if i > 1 {
-			m.Nick = m.Prefix[1:i]
-		}
+	if msg.Prefix != (irc.Prefix{}) {
+		m.Prefix = ":" + msg.Prefix.String()
+		m.Nick = msg.Prefix.Nick
+	}
+
+	m.Command = msg.Command
 
-		m.Command = fields[1]
-		if len(fields) >= 2 {
-			m.Channel = strings.TrimPrefix(fields[2], ":")
-			m.Args = fields[3:]
-		}
-	} else {
-		//message send from the server
-		m.Prefix = ""
-		m.Command = fields[0]
-		m.Args = fields[1:]
+	args := append([]string{}, msg.Params...)
+	if msg.HasTrailing {
+		args = append(args, msg.Trailing)
 	}
 
+	m.Channel = ""
+	m.Args = nil
+	if len(args) > 0 {
+		m.Channel = args[0]
+		m.Args = args[1:]
+	}
 }
 
 func Parseline(line string) *IrcMsg {
@@ -0,0 +1,124 @@
+package ircbot
+
+import (
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// tokenBucket is a simple token-bucket rate limiter: one token is added
+// every rate, up to burst tokens may accumulate, and take blocks until a
+// token is available. A non-positive rate disables limiting.
+type tokenBucket struct {
+	rate  time.Duration
+	burst int
+
+	mu     sync.Mutex
+	tokens int
+	last   time.Time
+}
+
+func newTokenBucket(rate time.Duration, burst int) *tokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: time.Now()}
+}
+
+func (tb *tokenBucket) take() {
+	if tb.rate <= 0 {
+		return
+	}
+
+	for {
+		tb.mu.Lock()
+		tb.refill()
+		if tb.tokens > 0 {
+			tb.tokens--
+			tb.mu.Unlock()
+			return
+		}
+		tb.mu.Unlock()
+		time.Sleep(tb.rate)
+	}
+}
+
+func (tb *tokenBucket) refill() {
+	elapsed := time.Since(tb.last)
+	add := int(elapsed / tb.rate)
+	if add <= 0 {
+		return
+	}
+
+	tb.tokens += add
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+	tb.last = tb.last.Add(time.Duration(add) * tb.rate)
+}
+
+// sender throttles outbound messages with a global token bucket plus, when
+// PerTargetRate is set, a bucket per target (channel or nick) so that one
+// flooded target can't starve the others.
+type sender struct {
+	bot *IrcBot
+
+	global *tokenBucket
+
+	mu      sync.Mutex
+	targets map[string]*tokenBucket
+}
+
+func newSender(bot *IrcBot) *sender {
+	return &sender{
+		bot:     bot,
+		global:  newTokenBucket(bot.SendRate, bot.SendBurst),
+		targets: make(map[string]*tokenBucket),
+	}
+}
+
+// wait blocks until a message to target is allowed to go out.
+func (s *sender) wait(target string) {
+	s.global.take()
+
+	if s.bot.PerTargetRate <= 0 {
+		return
+	}
+
+	s.mu.Lock()
+	tb, ok := s.targets[target]
+	if !ok {
+		tb = newTokenBucket(s.bot.PerTargetRate, s.bot.PerTargetBurst)
+		s.targets[target] = tb
+	}
+	s.mu.Unlock()
+
+	tb.take()
+}
+
+// splitMessage splits s into chunks of at most max bytes without breaking a
+// UTF-8 rune across two chunks. A non-positive max disables splitting.
+// If max is smaller than the encoding of the rune at a cut point, that rune
+// is cut at max bytes.
+func splitMessage(s string, max int) []string {
+	if max <= 0 || len(s) <= max {
+		return []string{s}
+	}
+
+	var chunks []string
+	for len(s) > max {
+		cut := max
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			cut = max
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
+	}
+	if len(s) > 0 {
+		chunks = append(chunks, s)
+	}
+	return chunks
+}
@@ -0,0 +1,55 @@
+package ircbot
+
+import (
+	"reflect"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		max  int
+		want []string
+	}{
+		{"fits in one chunk", "hello", 10, []string{"hello"}},
+		{"exact multiple of max", "abcdef", 3, []string{"abc", "def"}},
+		{"remainder kept as final chunk", "abcdefg", 3, []string{"abc", "def", "g"}},
+		{"non-positive max disables splitting", "hello world", 0, []string{"hello world"}},
+		{"empty string", "", 3, []string{""}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitMessage(tt.s, tt.max)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitMessage(%q, %d) = %q, want %q", tt.s, tt.max, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitMessageDoesNotBreakRunes(t *testing.T) {
+	// "héllo" is 6 bytes: h, é (2 bytes), l, l, o. A max of 2 would cut
+	// straight through é's second byte if rune boundaries weren't honored.
+	chunks := splitMessage("héllo", 2)
+
+	for _, c := range chunks {
+		if !utf8.ValidString(c) {
+			t.Fatalf("chunk %q is not valid UTF-8, rune was split across chunks", c)
+		}
+	}
+
+	if got := joinChunks(chunks); got != "héllo" {
+		t.Fatalf("joined chunks = %q, want %q", got, "héllo")
+	}
+}
+
+func joinChunks(chunks []string) string {
+	s := ""
+	for _, c := range chunks {
+		s += c
+	}
+	return s
+}
@@ -0,0 +1,164 @@
+// Package web exposes an IrcBot as an http.Handler: a small REST API plus a
+// WebSocket stream of every parsed message, so it can be mounted under a
+// caller's own router or TLS server instead of owning the whole process.
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Status is the JSON body returned by GET /status.
+type Status struct {
+	Server   string   `json:"server"`
+	Nick     string   `json:"nick"`
+	Joined   bool     `json:"joined"`
+	Channels []string `json:"channels"`
+}
+
+// Bot is the surface this package needs from an IrcBot. It keeps web
+// decoupled from package ircbot; IrcBot satisfies it structurally.
+type Bot interface {
+	ListChannels() []string
+	JoinChannel(name string)
+	PartChannel(name string)
+	SendMessage(target, text string)
+	Status() Status
+
+	// Subscribe registers a new /events listener. unsubscribe must be
+	// called once the listener is done to release it.
+	Subscribe() (events <-chan []byte, unsubscribe func())
+}
+
+// Config configures auth for the handler returned by NewHandler.
+type Config struct {
+	// Tokens maps a bearer token to the route patterns it may call. A
+	// pattern of "*" grants that token every route. A nil/empty Tokens
+	// disables auth entirely, allowing every request.
+	Tokens map[string][]string
+}
+
+func (cfg Config) allows(token, route string) bool {
+	if len(cfg.Tokens) == 0 {
+		return true
+	}
+
+	for _, pattern := range cfg.Tokens[token] {
+		if pattern == "*" || pattern == route {
+			return true
+		}
+	}
+
+	return false
+}
+
+func bearerToken(r *http.Request) string {
+	return strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+}
+
+// NewHandler builds the control plane: GET /channels, POST
+// /channels/{name}/join, POST /channels/{name}/part, POST /messages, GET
+// /status and the GET /events WebSocket stream.
+func NewHandler(bot Bot, cfg Config) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/status", authorize(cfg, "/status", handleStatus(bot)))
+	mux.HandleFunc("/channels", authorize(cfg, "/channels", handleChannels(bot)))
+	mux.HandleFunc("/channels/", handleChannelAction(cfg, bot))
+	mux.HandleFunc("/messages", authorize(cfg, "/messages", handleMessages(bot)))
+	mux.HandleFunc("/events", authorize(cfg, "/events", handleEvents(bot)))
+
+	return mux
+}
+
+func authorize(cfg Config, route string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !cfg.allows(bearerToken(r), route) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func handleStatus(bot Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, bot.Status())
+	}
+}
+
+func handleChannels(bot Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, bot.ListChannels())
+	}
+}
+
+func handleChannelAction(cfg Config, bot Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		rest := strings.Trim(strings.TrimPrefix(r.URL.Path, "/channels/"), "/")
+		parts := strings.Split(rest, "/")
+		if len(parts) != 2 || parts[0] == "" {
+			http.Error(w, "expected /channels/{name}/join|part", http.StatusBadRequest)
+			return
+		}
+
+		name, action := parts[0], parts[1]
+		if action != "join" && action != "part" {
+			http.Error(w, "unknown action "+action, http.StatusBadRequest)
+			return
+		}
+
+		if !cfg.allows(bearerToken(r), "/channels/"+action) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		if action == "join" {
+			bot.JoinChannel(name)
+		} else {
+			bot.PartChannel(name)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func handleMessages(bot Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body struct {
+			Target string `json:"target"`
+			Text   string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, "invalid JSON body", http.StatusBadRequest)
+			return
+		}
+		if body.Target == "" || body.Text == "" {
+			http.Error(w, "target and text are required", http.StatusBadRequest)
+			return
+		}
+
+		bot.SendMessage(body.Target, body.Text)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
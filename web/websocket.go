@@ -0,0 +1,109 @@
+package web
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// websocketGUID is the fixed key suffix defined by RFC 6455 used to compute
+// Sec-WebSocket-Accept from the client's Sec-WebSocket-Key.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// handleEvents upgrades the request to a WebSocket and streams each
+// message its subscription receives from bot's event bus as a JSON text
+// frame, until a write to the client fails.
+func handleEvents(bot Bot) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := upgrade(w, r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer conn.Close()
+
+		events, unsubscribe := bot.Subscribe()
+		defer unsubscribe()
+
+		for payload := range events {
+			if err := writeTextFrame(rw, payload); err != nil {
+				return
+			}
+			if err := rw.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// upgrade performs the RFC 6455 handshake and hands back the hijacked
+// connection for the caller to write frames on directly.
+func upgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return nil, nil, errors.New("web: not a websocket upgrade request")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("web: connection doesn't support hijacking")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+
+	if _, err := rw.WriteString(response); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, rw, nil
+}
+
+func acceptKey(clientKey string) string {
+	h := sha1.New()
+	h.Write([]byte(clientKey + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// writeTextFrame writes payload as a single, unmasked, final text frame.
+// Server-to-client frames are never masked per RFC 6455.
+func writeTextFrame(w io.Writer, payload []byte) error {
+	length := len(payload)
+
+	var header []byte
+	switch {
+	case length <= 125:
+		header = []byte{0x81, byte(length)}
+	case length <= 65535:
+		header = []byte{0x81, 126, byte(length >> 8), byte(length)}
+	default:
+		header = []byte{
+			0x81, 127,
+			byte(length >> 56), byte(length >> 48), byte(length >> 40), byte(length >> 32),
+			byte(length >> 24), byte(length >> 16), byte(length >> 8), byte(length),
+		}
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(payload)
+	return err
+}
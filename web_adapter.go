@@ -0,0 +1,105 @@
+package ircbot
+
+import (
+	"sync"
+
+	"github.com/Zaibon/ircbot-lib/web"
+)
+
+// eventBus fans out published payloads to every active /events subscriber.
+// A subscriber whose 16-slot buffer is full misses the payload; publish
+// never blocks on it.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[chan []byte]struct{}
+}
+
+func newEventBus() *eventBus {
+	return &eventBus{subs: make(map[chan []byte]struct{})}
+}
+
+func (b *eventBus) subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, 16)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+		close(ch)
+	}
+
+	return ch, unsubscribe
+}
+
+func (b *eventBus) publish(payload []byte) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- payload:
+		default:
+		}
+	}
+}
+
+// The methods below implement web.Bot, letting IrcBot drive the REST +
+// WebSocket control plane exposed by HandleWeb/WebHandler.
+
+func (b *IrcBot) ListChannels() []string {
+	b.channelMu.Lock()
+	defer b.channelMu.Unlock()
+	return append([]string{}, b.Channel...)
+}
+
+func (b *IrcBot) JoinChannel(name string) {
+	b.channelMu.Lock()
+	b.Channel = append(b.Channel, name)
+	b.channelMu.Unlock()
+
+	msg := NewIrcMsg()
+	msg.Command = "JOIN"
+	msg.Channel = name
+	b.enqueue(msg)
+}
+
+func (b *IrcBot) PartChannel(name string) {
+	b.channelMu.Lock()
+	for i, ch := range b.Channel {
+		if ch == name {
+			b.Channel = append(b.Channel[:i], b.Channel[i+1:]...)
+			break
+		}
+	}
+	b.channelMu.Unlock()
+
+	msg := NewIrcMsg()
+	msg.Command = "PART"
+	msg.Channel = name
+	b.enqueue(msg)
+}
+
+func (b *IrcBot) SendMessage(target, text string) {
+	msg := NewIrcMsg()
+	msg.Command = "PRIVMSG"
+	msg.Channel = target
+	msg.Args = []string{text}
+	b.enqueue(msg)
+}
+
+func (b *IrcBot) Status() web.Status {
+	return web.Status{
+		Server:   b.Server,
+		Nick:     b.Nick,
+		Joined:   b.Joined,
+		Channels: b.ListChannels(),
+	}
+}
+
+func (b *IrcBot) Subscribe() (<-chan []byte, func()) {
+	return b.webEvents.subscribe()
+}